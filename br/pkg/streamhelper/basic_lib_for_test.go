@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	backup "github.com/pingcap/kvproto/pkg/brpb"
 	"github.com/pingcap/kvproto/pkg/errorpb"
@@ -24,6 +25,7 @@ import (
 	"github.com/pingcap/tidb/kv"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type flushSimulator struct {
@@ -69,6 +71,21 @@ type region struct {
 type fakeStore struct {
 	id      uint64
 	regions map[uint64]*region
+
+	inflightMu  sync.Mutex
+	inflight    int
+	maxInflight int
+
+	// latency, if set, is slept at the start of every
+	// GetLastFlushTSOfRegion call, to simulate a hung or slow store.
+	latency time.Duration
+	// lastSource records the request-source label seen on the most recent
+	// call, read through gRPC metadata.
+	lastSource string
+
+	// partitioned, once set by fakeCluster.partitionLeader, makes every
+	// call to this store fail as if it were unreachable.
+	partitioned bool
 }
 
 type fakeCluster struct {
@@ -110,24 +127,73 @@ func (r *region) flush() {
 	r.fsim.flushedEpoch = r.epoch
 }
 
+// enterInflight records the call as in-flight, updating the high-water
+// mark observed so tests can assert on the concurrency seen by a batching
+// Collector. The returned func must be called once the call completes.
+func (f *fakeStore) enterInflight() func() {
+	f.inflightMu.Lock()
+	f.inflight++
+	if f.inflight > f.maxInflight {
+		f.maxInflight = f.inflight
+	}
+	f.inflightMu.Unlock()
+
+	return func() {
+		f.inflightMu.Lock()
+		f.inflight--
+		f.inflightMu.Unlock()
+	}
+}
+
+func (f *fakeStore) observedMaxInflight() int {
+	f.inflightMu.Lock()
+	defer f.inflightMu.Unlock()
+	return f.maxInflight
+}
+
+func (f *fakeStore) observedRequestSource() string {
+	f.inflightMu.Lock()
+	defer f.inflightMu.Unlock()
+	return f.lastSource
+}
+
 func (f *fakeStore) GetLastFlushTSOfRegion(ctx context.Context, in *logbackup.GetLastFlushTSOfRegionRequest, opts ...grpc.CallOption) (*logbackup.GetLastFlushTSOfRegionResponse, error) {
+	defer f.enterInflight()()
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if vs := md.Get("source"); len(vs) > 0 {
+			f.inflightMu.Lock()
+			f.lastSource = vs[0]
+			f.inflightMu.Unlock()
+		}
+	}
+	f.inflightMu.Lock()
+	partitioned := f.partitioned
+	f.inflightMu.Unlock()
+	if partitioned {
+		return nil, streamhelper.ErrStoreUnreachable
+	}
+	if f.latency > 0 {
+		select {
+		case <-time.After(f.latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 	resp := &logbackup.GetLastFlushTSOfRegionResponse{
 		Checkpoints: []*logbackup.RegionCheckpoint{},
 	}
 	for _, r := range in.Regions {
 		region, ok := f.regions[r.Id]
-		if !ok || region.leader != f.id {
+		if !ok {
 			resp.Checkpoints = append(resp.Checkpoints, &logbackup.RegionCheckpoint{
-				Err: &errorpb.Error{
-					Message: "not found",
-				},
-				Region: &logbackup.RegionIdentity{
-					Id:           region.id,
-					EpochVersion: region.epoch,
-				},
+				Err:    &errorpb.Error{Message: "not found"},
+				Region: &logbackup.RegionIdentity{Id: r.Id, EpochVersion: r.EpochVersion},
 			})
 			continue
 		}
+		// A follower answers from its own copy of the region, which may trail
+		// the leader's (see staleFollower): the follower fallback path reads
+		// such an answer as a lower bound.
 		if err := region.fsim.makeError(r.EpochVersion); err != nil {
 			resp.Checkpoints = append(resp.Checkpoints, &logbackup.RegionCheckpoint{
 				Err: err,
@@ -195,6 +261,42 @@ func (f *fakeCluster) RegionScan(ctx context.Context, key []byte, endKey []byte,
 	return result, nil
 }
 
+// RegionScanReverse returns regions in descending start-key order, between
+// lowerBound (inclusive) and startKey (exclusive). A nil startKey means
+// "the end of the keyspace".
+func (f *fakeCluster) RegionScanReverse(ctx context.Context, startKey, lowerBound []byte, limit int) ([]streamhelper.RegionWithLeader, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sort.Slice(f.regions, func(i, j int) bool {
+		return bytes.Compare(f.regions[i].rng.StartKey, f.regions[j].rng.StartKey) < 0
+	})
+
+	result := make([]streamhelper.RegionWithLeader, 0, limit)
+	for i := len(f.regions) - 1; i >= 0 && len(result) < limit; i-- {
+		region := f.regions[i]
+		if startKey != nil && bytes.Compare(region.rng.StartKey, startKey) >= 0 {
+			continue
+		}
+		if bytes.Compare(region.rng.StartKey, lowerBound) < 0 {
+			break
+		}
+		result = append(result, streamhelper.RegionWithLeader{
+			Region: &metapb.Region{
+				Id:       region.id,
+				StartKey: region.rng.StartKey,
+				EndKey:   region.rng.EndKey,
+				RegionEpoch: &metapb.RegionEpoch{
+					Version: region.epoch,
+				},
+			},
+			Leader: &metapb.Peer{
+				StoreId: region.leader,
+			},
+		})
+	}
+	return result, nil
+}
+
 func (f *fakeCluster) GetLogBackupClient(ctx context.Context, storeID uint64) (logbackup.LogBackupClient, error) {
 	if f.onGetClient != nil {
 		err := f.onGetClient(storeID)
@@ -241,6 +343,27 @@ storeLoop:
 	}
 }
 
+// staleFollower splits storeID off from the shared *region instance it
+// otherwise holds in common with every other peer of rid, giving it its own
+// copy frozen at the region's current checkpoint. Callers can then advance
+// the region's checkpoint further (e.g. via advanceCheckpoints) without that
+// being reflected on storeID, letting a test construct a follower that is
+// genuinely behind rather than merely unreachable.
+func (f *fakeCluster) staleFollower(storeID, rid uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	store, ok := f.stores[storeID]
+	if !ok {
+		f.testCtx.Fatalf("the store %d doesn't exist", storeID)
+	}
+	r, ok := store.regions[rid]
+	if !ok {
+		f.testCtx.Fatalf("store %d doesn't hold region %d", storeID, rid)
+	}
+	frozen := *r
+	store.regions[rid] = &frozen
+}
+
 func (f *fakeCluster) splitAt(key string) {
 	k := []byte(key)
 	r := f.findRegionByKey(k)
@@ -279,6 +402,29 @@ func (f *fakeCluster) findPeers(rid uint64) (result []uint64) {
 	return
 }
 
+// Peers implements streamhelper.PeerLister, letting a Collector configured
+// with PreferLeader/AnyPeer discover a region's followers.
+func (f *fakeCluster) Peers(ctx context.Context, regionID uint64) ([]uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.findPeers(regionID), nil
+}
+
+// partitionLeader makes storeID unreachable, as if it were cut off by a
+// network partition, so tests can exercise the leader-unreachable fallback
+// path.
+func (f *fakeCluster) partitionLeader(storeID uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.stores[storeID]
+	if !ok {
+		f.testCtx.Fatalf("the store %d doesn't exist", storeID)
+	}
+	s.inflightMu.Lock()
+	s.partitioned = true
+	s.inflightMu.Unlock()
+}
+
 func (f *fakeCluster) shuffleLeader(rid uint64) {
 	r := f.findRegionById(rid)
 	peers := f.findPeers(rid)
@@ -361,15 +507,61 @@ func createFakeCluster(t *testing.T, n int, simEnabled bool) *fakeCluster {
 	return c
 }
 
+// NewFakeClusterFromDump reconstructs a fakeCluster whose regions mirror
+// summary, so a dump-checkpoints file captured against a failed production
+// advance can be replayed against streamhelper's own unit tests. Every
+// region is only known to its leader store: a dump doesn't record follower
+// placement, so the replayed cluster has no followers to query until the
+// test sets them up itself (e.g. via transferRegionTo).
+func NewFakeClusterFromDump(t *testing.T, summary streamhelper.DumpSummary) *fakeCluster {
+	c := &fakeCluster{
+		stores:  map[uint64]*fakeStore{},
+		regions: []*region{},
+		testCtx: t,
+	}
+	for _, rec := range summary.Regions {
+		startKey, err := hex.DecodeString(rec.StartKey)
+		if err != nil {
+			t.Fatalf("dump record %d has an invalid start key: %v", rec.ID, err)
+		}
+		endKey, err := hex.DecodeString(rec.EndKey)
+		if err != nil {
+			t.Fatalf("dump record %d has an invalid end key: %v", rec.ID, err)
+		}
+		r := &region{
+			rng:        kv.KeyRange{StartKey: startKey, EndKey: endKey},
+			leader:     rec.LeaderStore,
+			epoch:      rec.Epoch,
+			id:         rec.ID,
+			checkpoint: rec.LastFlushTS,
+		}
+		if r.id > c.idAlloced {
+			c.idAlloced = r.id
+		}
+		c.regions = append(c.regions, r)
+
+		store, ok := c.stores[rec.LeaderStore]
+		if !ok {
+			store = &fakeStore{id: rec.LeaderStore, regions: map[uint64]*region{}}
+			c.stores[rec.LeaderStore] = store
+		}
+		store.regions[r.id] = r
+	}
+	return c
+}
+
+// String delegates to DumpRecord's formatting, appending the fsim state that
+// only the fake cluster tracks.
 func (r *region) String() string {
-	return fmt.Sprintf("%d(%d):[%s,%s);%dL%dF%d",
-		r.id,
-		r.epoch,
-		hex.EncodeToString(r.rng.StartKey),
-		hex.EncodeToString(r.rng.EndKey),
-		r.checkpoint,
-		r.leader,
-		r.fsim.flushedEpoch)
+	rec := streamhelper.DumpRecord{
+		ID:          r.id,
+		Epoch:       r.epoch,
+		StartKey:    hex.EncodeToString(r.rng.StartKey),
+		EndKey:      hex.EncodeToString(r.rng.EndKey),
+		LeaderStore: r.leader,
+		LastFlushTS: r.checkpoint,
+	}
+	return fmt.Sprintf("%sF%d", rec, r.fsim.flushedEpoch)
 }
 
 func (f *fakeStore) String() string {
@@ -0,0 +1,252 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	logbackup "github.com/pingcap/kvproto/pkg/logbackuppb"
+)
+
+// BatchConfig controls how a Collector groups and throttles
+// GetLastFlushTSOfRegion RPCs issued against the log-backup stores.
+type BatchConfig struct {
+	// MaxInflightPerStore bounds the number of concurrent RPCs sent to a
+	// single store.
+	MaxInflightPerStore int
+	// MaxBatchSize bounds the number of regions carried by a single RPC.
+	MaxBatchSize int
+	// CoalesceWindow is how long pending requests for a store are held
+	// before being flushed, so requests for the same region-id that arrive
+	// within the window collapse into a single physical RPC.
+	CoalesceWindow time.Duration
+}
+
+// DefaultBatchConfig returns the batching parameters the advancer uses when
+// the caller doesn't override them.
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		MaxInflightPerStore: 4,
+		MaxBatchSize:        4096,
+		CoalesceWindow:      20 * time.Millisecond,
+	}
+}
+
+// RegionCheckpoint is the result of querying the last flush ts of a region,
+// fanned in from whichever store batch served it.
+type RegionCheckpoint struct {
+	Region     RegionWithLeader
+	Checkpoint uint64
+	Err        error
+	// IsLowerBound is set when Checkpoint came from a follower fallback
+	// (see LeaderPreferenceMode) rather than the region's leader, meaning
+	// the advancer must not advance past it without also accounting for
+	// the fact that the true checkpoint may be higher.
+	IsLowerBound bool
+}
+
+// LogBackupMetaClient is the subset of the metadata client a Collector needs
+// in order to reach log-backup stores.
+type LogBackupMetaClient interface {
+	GetLogBackupClient(ctx context.Context, storeID uint64) (logbackup.LogBackupClient, error)
+}
+
+// Collector batches GetLastFlushTSOfRegion requests by store and dispatches
+// them through a bounded worker pool, coalescing overlapping requests for
+// the same region within a short time window so that a single tick of the
+// advancer produces at most one physical RPC per store even under
+// thousands of regions.
+type Collector struct {
+	cluster LogBackupMetaClient
+	cfg     CollectorConfig
+
+	mu     sync.Mutex
+	stores map[uint64]*storeBatch
+}
+
+// NewCollector creates a Collector that talks to cluster using cfg.
+func NewCollector(cluster LogBackupMetaClient, cfg CollectorConfig) *Collector {
+	return &Collector{
+		cluster: cluster,
+		cfg:     sanitizeBatchConfig(cfg),
+		stores:  map[uint64]*storeBatch{},
+	}
+}
+
+// sanitizeBatchConfig clamps cfg's batching parameters to a sane minimum of
+// 1, so a caller who builds a CollectorConfig by hand (rather than starting
+// from DefaultCollectorConfig) can't leave MaxInflightPerStore at its
+// zero-value default -- an unbuffered semaphore channel would otherwise
+// deadlock storeBatch.flush on its very first send.
+func sanitizeBatchConfig(cfg CollectorConfig) CollectorConfig {
+	if cfg.MaxInflightPerStore < 1 {
+		cfg.MaxInflightPerStore = 1
+	}
+	if cfg.MaxBatchSize < 1 {
+		cfg.MaxBatchSize = 1
+	}
+	return cfg
+}
+
+// SubscribeCheckpoints queries the last flush ts of every region in regions
+// and returns the results on a fan-in channel, closed once all of them have
+// been answered. Requests for the same region-id that arrive at the same
+// store within CoalesceWindow of each other are served by a single RPC.
+func (c *Collector) SubscribeCheckpoints(ctx context.Context, regions []RegionWithLeader) <-chan RegionCheckpoint {
+	out := make(chan RegionCheckpoint, len(regions))
+	if len(regions) == 0 {
+		close(out)
+		return out
+	}
+	remaining := int64(len(regions))
+	deliver := func(cp RegionCheckpoint) {
+		out <- cp
+		if atomic.AddInt64(&remaining, -1) == 0 {
+			close(out)
+		}
+	}
+	for _, r := range regions {
+		c.storeBatchFor(r.Leader.StoreId).add(ctx, r, deliver)
+	}
+	return out
+}
+
+func (c *Collector) storeBatchFor(storeID uint64) *storeBatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.stores[storeID]
+	if !ok {
+		b = &storeBatch{
+			storeID: storeID,
+			cfg:     c.cfg,
+			cluster: c.cluster,
+			sem:     make(chan struct{}, c.cfg.MaxInflightPerStore),
+			pending: map[uint64]*pendingRegion{},
+		}
+		c.stores[storeID] = b
+	}
+	return b
+}
+
+// pendingRegion is a region-id awaiting its next flush, along with every
+// caller that asked for its checkpoint within the current coalesce window.
+type pendingRegion struct {
+	region  RegionWithLeader
+	waiters []func(RegionCheckpoint)
+}
+
+// storeBatch accumulates pending region requests for one store and flushes
+// them either once cfg.CoalesceWindow elapses or once cfg.MaxBatchSize is
+// reached, never running more than cfg.MaxInflightPerStore RPCs against the
+// store concurrently.
+type storeBatch struct {
+	storeID uint64
+	cfg     CollectorConfig
+	cluster LogBackupMetaClient
+	sem     chan struct{}
+
+	mu      sync.Mutex
+	pending map[uint64]*pendingRegion
+	timer   *time.Timer
+}
+
+func (b *storeBatch) add(ctx context.Context, r RegionWithLeader, deliver func(RegionCheckpoint)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.pending[r.Region.Id]; ok {
+		p.waiters = append(p.waiters, deliver)
+		return
+	}
+	b.pending[r.Region.Id] = &pendingRegion{region: r, waiters: []func(RegionCheckpoint){deliver}}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.cfg.CoalesceWindow, func() { b.flush(ctx) })
+	}
+	if len(b.pending) >= b.cfg.MaxBatchSize {
+		b.timer.Stop()
+		go b.flush(ctx)
+	}
+}
+
+func (b *storeBatch) flush(baseCtx context.Context) {
+	b.mu.Lock()
+	if len(b.pending) == 0 {
+		b.timer = nil
+		b.mu.Unlock()
+		return
+	}
+	batch := b.pending
+	b.pending = map[uint64]*pendingRegion{}
+	b.timer = nil
+	b.mu.Unlock()
+
+	b.sem <- struct{}{}
+	defer func() { <-b.sem }()
+
+	// deliver sends cp for region p to every caller waiting on it, falling
+	// back to a follower read when cp carries a retryable error and the
+	// collector is configured to prefer that over surfacing the error.
+	// Fallback queries are derived from baseCtx rather than the
+	// (possibly already-expired) leader-RPC context below, so a
+	// DeadlineExceeded leader error doesn't doom the follower attempts too.
+	deliver := func(p *pendingRegion, cp uint64, err error) {
+		if err != nil {
+			if fb, ok := resolveFallback(withRequestSource(baseCtx, b.cfg.RequestSource), b.cfg.PerRegionRPCTimeout, b.cluster, b.cfg.Peers, b.cfg.LeaderPreference, p.region, err); ok {
+				cp, err = fb, nil
+				for _, w := range p.waiters {
+					w(RegionCheckpoint{Region: p.region, Checkpoint: cp, IsLowerBound: true})
+				}
+				return
+			}
+		}
+		for _, w := range p.waiters {
+			w(RegionCheckpoint{Region: p.region, Checkpoint: cp, Err: err})
+		}
+	}
+
+	fail := func(err error) {
+		for _, p := range batch {
+			deliver(p, 0, err)
+		}
+	}
+
+	ctx := baseCtx
+	if b.cfg.PerRegionRPCTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.PerRegionRPCTimeout)
+		defer cancel()
+	}
+	ctx = withRequestSource(ctx, b.cfg.RequestSource)
+
+	cli, err := b.cluster.GetLogBackupClient(ctx, b.storeID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	req := &logbackup.GetLastFlushTSOfRegionRequest{}
+	for _, p := range batch {
+		req.Regions = append(req.Regions, &logbackup.RegionIdentity{
+			Id:           p.region.Region.Id,
+			EpochVersion: p.region.Region.RegionEpoch.Version,
+		})
+	}
+	resp, err := cli.GetLastFlushTSOfRegion(ctx, req)
+	if err != nil {
+		fail(err)
+		return
+	}
+	for _, cp := range resp.Checkpoints {
+		p, ok := batch[cp.Region.Id]
+		if !ok {
+			continue
+		}
+		var rerr error
+		if cp.Err != nil {
+			rerr = errors.New(cp.Err.Message)
+		}
+		deliver(p, cp.Checkpoint, rerr)
+	}
+}
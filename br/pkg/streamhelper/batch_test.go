@@ -0,0 +1,103 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/br/pkg/streamhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectorBatchesByStore(t *testing.T) {
+	c := createFakeCluster(t, 4, false)
+	keys := make([]string, 0, 256)
+	for i := 0; i < 256; i++ {
+		keys = append(keys, fmt.Sprintf("%04d", i))
+	}
+	c.splitAndScatter(keys...)
+
+	regions, err := c.RegionScan(context.Background(), nil, nil, len(c.regions)+1)
+	require.NoError(t, err)
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	cfg.MaxInflightPerStore = 1
+	cfg.MaxBatchSize = 4096
+	cfg.CoalesceWindow = 20 * time.Millisecond
+	collector := streamhelper.NewCollector(c, cfg)
+	ch := collector.SubscribeCheckpoints(context.Background(), regions)
+
+	seen := map[uint64]struct{}{}
+	for cp := range ch {
+		require.NoError(t, cp.Err)
+		seen[cp.Region.Region.Id] = struct{}{}
+	}
+	require.Len(t, seen, len(regions))
+
+	for _, s := range c.stores {
+		require.LessOrEqualf(t, s.observedMaxInflight(), cfg.MaxInflightPerStore,
+			"store %d exceeded MaxInflightPerStore", s.id)
+	}
+}
+
+func TestCollectorDedupesWithinWindow(t *testing.T) {
+	c := createFakeCluster(t, 1, false)
+	region, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, region, 1)
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	collector := streamhelper.NewCollector(c, cfg)
+
+	// Ask for the same region's checkpoint twice within the coalesce
+	// window: both requests should be served, but by a single RPC.
+	ch1 := collector.SubscribeCheckpoints(context.Background(), region)
+	ch2 := collector.SubscribeCheckpoints(context.Background(), region)
+
+	cp1 := <-ch1
+	cp2 := <-ch2
+	require.NoError(t, cp1.Err)
+	require.NoError(t, cp2.Err)
+	require.Equal(t, cp1.Checkpoint, cp2.Checkpoint)
+
+	store := c.stores[1]
+	require.Equal(t, 1, store.observedMaxInflight())
+}
+
+func TestCollectorClampsZeroValueBatchConfig(t *testing.T) {
+	c := createFakeCluster(t, 1, false)
+	region, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, region, 1)
+
+	// A hand-built CollectorConfig that never went through
+	// DefaultCollectorConfig leaves MaxInflightPerStore and MaxBatchSize at
+	// their zero value. NewCollector must clamp these itself: an unbuffered
+	// semaphore channel would otherwise deadlock storeBatch.flush forever.
+	collector := streamhelper.NewCollector(c, streamhelper.CollectorConfig{RequestSource: "test"})
+
+	ch := collector.SubscribeCheckpoints(context.Background(), region)
+	select {
+	case cp, ok := <-ch:
+		require.True(t, ok)
+		require.NoError(t, cp.Err)
+	case <-time.After(time.Second):
+		t.Fatal("SubscribeCheckpoints never delivered a checkpoint with a zero-value CollectorConfig")
+	}
+}
+
+func TestCollectorSubscribeCheckpointsOnEmptyRegionsClosesImmediately(t *testing.T) {
+	c := createFakeCluster(t, 1, false)
+	collector := streamhelper.NewCollector(c, streamhelper.DefaultCollectorConfig())
+
+	ch := collector.SubscribeCheckpoints(context.Background(), nil)
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok, "channel should be closed with no values for an empty region set")
+	case <-time.After(time.Second):
+		t.Fatal("SubscribeCheckpoints with no regions never closed its channel")
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// requestSourceMetadataKey is the gRPC metadata key used to label the
+// workload issuing a request, mirroring client-go's request-source header.
+const requestSourceMetadataKey = "source"
+
+// DefaultRequestSource identifies the log-backup advancer itself, as
+// opposed to e.g. lightning or BR restore traffic sharing the same stores.
+const DefaultRequestSource = "log-backup-advancer"
+
+// CollectorConfig configures a Collector: how it batches requests, and how
+// it labels and bounds the RPCs it sends.
+type CollectorConfig struct {
+	BatchConfig
+
+	// RequestSource labels every GetLastFlushTSOfRegion RPC so it can be
+	// told apart from other workloads sharing the store.
+	RequestSource string
+	// PerRegionRPCTimeout bounds how long a single store's RPC may run
+	// before it is abandoned, so one hung store cannot stall an entire
+	// advancement tick.
+	PerRegionRPCTimeout time.Duration
+
+	// LeaderPreference controls what the Collector does when a region's
+	// leader can't be reached. It defaults to LeaderOnly.
+	LeaderPreference LeaderPreferenceMode
+	// Peers lists a region's replicas, and is required when
+	// LeaderPreference is not LeaderOnly.
+	Peers PeerLister
+}
+
+// DefaultCollectorConfig returns the configuration the advancer uses when
+// the caller doesn't override it.
+func DefaultCollectorConfig() CollectorConfig {
+	return CollectorConfig{
+		BatchConfig:         DefaultBatchConfig(),
+		RequestSource:       DefaultRequestSource,
+		PerRegionRPCTimeout: 30 * time.Second,
+	}
+}
+
+// withRequestSource attaches cfg's request source to ctx's outgoing gRPC
+// metadata, so the receiving store can see who issued the request.
+func withRequestSource(ctx context.Context, source string) context.Context {
+	if source == "" {
+		source = DefaultRequestSource
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestSourceMetadataKey, source)
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/br/pkg/streamhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectorLabelsRequestSource(t *testing.T) {
+	c := createFakeCluster(t, 1, false)
+	regions, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	cfg.RequestSource = "lightning"
+	collector := streamhelper.NewCollector(c, cfg)
+
+	for cp := range collector.SubscribeCheckpoints(context.Background(), regions) {
+		require.NoError(t, cp.Err)
+	}
+	require.Equal(t, "lightning", c.stores[1].observedRequestSource())
+}
+
+func TestCollectorSurfacesPerRegionTimeoutWithoutFailingOtherRegions(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	c.splitAndScatter("m")
+	// Pin each region's leader so the test isn't sensitive to
+	// splitAndScatter's random leader shuffle.
+	require.Len(t, c.regions, 2)
+	c.updateRegion(c.regions[0].id, func(r *region) { r.leader = 1 })
+	c.updateRegion(c.regions[1].id, func(r *region) { r.leader = 2 })
+
+	regions, err := c.RegionScan(context.Background(), nil, nil, len(c.regions)+1)
+	require.NoError(t, err)
+	require.Len(t, regions, 2)
+
+	// Make one store hang well past the configured per-region timeout.
+	hungStore := regions[0].Leader.StoreId
+	c.stores[hungStore].latency = time.Second
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	cfg.PerRegionRPCTimeout = 10 * time.Millisecond
+	collector := streamhelper.NewCollector(c, cfg)
+
+	var ok, failed int
+	for cp := range collector.SubscribeCheckpoints(context.Background(), regions) {
+		if cp.Err != nil {
+			require.True(t, errors.Is(cp.Err, context.DeadlineExceeded))
+			failed++
+		} else {
+			ok++
+		}
+	}
+	require.Equal(t, 1, failed)
+	require.Equal(t, 1, ok)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// NewDumpCheckpointsCommand builds the `dump-checkpoints` subcommand mounted
+// under `br debug log-backup`. It walks the same RegionScan path the
+// advancer uses and writes every region's checkpoint state to a file, for
+// offline diagnostics.
+func NewDumpCheckpointsCommand(newDumper func(ctx context.Context) (Dumper, error)) *cobra.Command {
+	var (
+		output string
+		format string
+	)
+	cmd := &cobra.Command{
+		Use:   "dump-checkpoints",
+		Short: "Dump every region's log-backup checkpoint state to a file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dumper, err := newDumper(cmd.Context())
+			if err != nil {
+				return err
+			}
+			summary, err := dumper.Dump(cmd.Context())
+			if err != nil {
+				return err
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			switch format {
+			case "csv":
+				err = WriteCSV(f, summary)
+			default:
+				err = WriteNDJSON(f, summary)
+			}
+			if err != nil {
+				return err
+			}
+			cmd.Println(summary.Summary())
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "checkpoints.ndjson", "path to write the dump to")
+	cmd.Flags().StringVar(&format, "format", "ndjson", "output format: ndjson or csv")
+	return cmd
+}
+
+// NewLoadCheckpointsCommand builds the `load-checkpoints` companion
+// subcommand, which parses an NDJSON dump produced by dump-checkpoints and
+// prints its summary. It does not read CSV dumps, and it does not build a
+// cluster from the dump.
+func NewLoadCheckpointsCommand() *cobra.Command {
+	var input string
+	cmd := &cobra.Command{
+		Use:   "load-checkpoints",
+		Short: "Parse a dump-checkpoints file and print its summary",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(input)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			summary, err := LoadNDJSON(f)
+			if err != nil {
+				return err
+			}
+			cmd.Println(summary.Summary())
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&input, "input", "checkpoints.ndjson", "path to the dump-checkpoints output to load")
+	return cmd
+}
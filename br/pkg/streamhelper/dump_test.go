@@ -0,0 +1,108 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb/br/pkg/streamhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanDumperRoundTrip(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	c.splitAndScatter("a", "b", "c", "d")
+	global := c.advanceCheckpoints()
+
+	collector := streamhelper.NewCollector(c, streamhelper.DefaultCollectorConfig())
+	dumper := streamhelper.NewScanDumper(c, collector, func(ctx context.Context) (uint64, error) {
+		return global, nil
+	})
+
+	summary, err := dumper.Dump(context.Background())
+	require.NoError(t, err)
+	require.Len(t, summary.Regions, len(c.regions))
+	require.Equal(t, global, summary.GlobalCheckpoint)
+
+	var buf bytes.Buffer
+	require.NoError(t, streamhelper.WriteNDJSON(&buf, summary))
+
+	reloaded, err := streamhelper.LoadNDJSON(&buf)
+	require.NoError(t, err)
+	require.Equal(t, summary.GlobalCheckpoint, reloaded.GlobalCheckpoint)
+	require.ElementsMatch(t, summary.Regions, reloaded.Regions)
+}
+
+func TestLoadNDJSONRoundTripsZeroGlobalCheckpoint(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	c.splitAndScatter("a", "b")
+	// No advanceCheckpoints call: the task is freshly initialized, so the
+	// global checkpoint is a legitimate zero rather than a sentinel.
+	const global = uint64(0)
+
+	collector := streamhelper.NewCollector(c, streamhelper.DefaultCollectorConfig())
+	dumper := streamhelper.NewScanDumper(c, collector, func(ctx context.Context) (uint64, error) {
+		return global, nil
+	})
+	summary, err := dumper.Dump(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, global, summary.GlobalCheckpoint)
+
+	var buf bytes.Buffer
+	require.NoError(t, streamhelper.WriteNDJSON(&buf, summary))
+
+	reloaded, err := streamhelper.LoadNDJSON(&buf)
+	require.NoError(t, err)
+	require.Equal(t, global, reloaded.GlobalCheckpoint)
+	require.ElementsMatch(t, summary.Regions, reloaded.Regions)
+}
+
+func TestNewFakeClusterFromDumpReplaysProductionDump(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	c.splitAndScatter("a", "b", "c", "d")
+	global := c.advanceCheckpoints()
+
+	collector := streamhelper.NewCollector(c, streamhelper.DefaultCollectorConfig())
+	dumper := streamhelper.NewScanDumper(c, collector, func(ctx context.Context) (uint64, error) {
+		return global, nil
+	})
+	summary, err := dumper.Dump(context.Background())
+	require.NoError(t, err)
+
+	replayed := NewFakeClusterFromDump(t, summary)
+	region, err := replayed.RegionScan(context.Background(), nil, nil, 4096)
+	require.NoError(t, err)
+	require.Len(t, region, len(summary.Regions))
+
+	replayedCollector := streamhelper.NewCollector(replayed, streamhelper.DefaultCollectorConfig())
+	for cp := range replayedCollector.SubscribeCheckpoints(context.Background(), region) {
+		require.NoError(t, cp.Err)
+		var want uint64
+		for _, rec := range summary.Regions {
+			if rec.ID == cp.Region.Region.Id {
+				want = rec.LastFlushTS
+			}
+		}
+		require.Equal(t, want, cp.Checkpoint)
+	}
+}
+
+func TestWriteCSVIncludesEveryRegion(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	c.splitAndScatter("m")
+	global := c.advanceCheckpoints()
+
+	collector := streamhelper.NewCollector(c, streamhelper.DefaultCollectorConfig())
+	dumper := streamhelper.NewScanDumper(c, collector, func(ctx context.Context) (uint64, error) {
+		return global, nil
+	})
+	summary, err := dumper.Dump(context.Background())
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, streamhelper.WriteCSV(&buf, summary))
+	// header + one row per region
+	require.Equal(t, len(summary.Regions)+1, bytes.Count(buf.Bytes(), []byte("\n")))
+}
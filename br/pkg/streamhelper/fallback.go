@@ -0,0 +1,148 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	logbackup "github.com/pingcap/kvproto/pkg/logbackuppb"
+)
+
+// ErrStoreUnreachable is returned by a LogBackupMetaClient when the store
+// cannot be reached at all (as opposed to the store answering with a
+// per-region error).
+var ErrStoreUnreachable = errors.New("store unreachable")
+
+// LeaderPreferenceMode controls how a Collector reacts when a region's
+// leader can't answer a checkpoint query.
+type LeaderPreferenceMode int
+
+const (
+	// LeaderOnly only ever queries the region's leader; a leader error is
+	// surfaced as-is. This is the original behavior.
+	LeaderOnly LeaderPreferenceMode = iota
+	// PreferLeader queries the leader first, and on a retryable error
+	// (NotLeader, store unreachable, or a timed-out context) falls back to
+	// querying followers one at a time, stopping at the first that
+	// answers. The returned checkpoint is treated as a lower bound, since
+	// a follower's applied checkpoint can trail the leader's.
+	PreferLeader
+	// AnyPeer, on the same retryable leader errors, queries every follower
+	// and takes the minimum of whichever answer, also treated as a lower
+	// bound. A leader that answers is used as-is: followers are then not
+	// queried, and the leader is not queried again during the fallback.
+	AnyPeer
+)
+
+// PeerLister lists every store holding a replica of a region, so a
+// Collector can retry against followers when the leader is unreachable.
+type PeerLister interface {
+	Peers(ctx context.Context, regionID uint64) ([]uint64, error)
+}
+
+// isRetryable reports whether err is the kind of per-region failure that a
+// PreferLeader/AnyPeer Collector should retry against followers, rather
+// than surface immediately.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrStoreUnreachable) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not leader") || strings.Contains(msg, "unreachable")
+}
+
+// queryPeer issues a single-region GetLastFlushTSOfRegion RPC directly
+// against storeID, bypassing the usual per-store batching; it is only used
+// for the rare leader-unreachable fallback path.
+func queryPeer(ctx context.Context, cluster LogBackupMetaClient, storeID uint64, region RegionWithLeader) (uint64, error) {
+	cli, err := cluster.GetLogBackupClient(ctx, storeID)
+	if err != nil {
+		return 0, err
+	}
+	req := &logbackup.GetLastFlushTSOfRegionRequest{
+		Regions: []*logbackup.RegionIdentity{{
+			Id:           region.Region.Id,
+			EpochVersion: region.Region.RegionEpoch.Version,
+		}},
+	}
+	resp, err := cli.GetLastFlushTSOfRegion(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Checkpoints) == 0 {
+		return 0, errors.New("peer returned no checkpoint")
+	}
+	cp := resp.Checkpoints[0]
+	if cp.Err != nil {
+		return 0, errors.New(cp.Err.Message)
+	}
+	return cp.Checkpoint, nil
+}
+
+// resolveFallback retries a region whose leader query failed with leaderErr
+// against its followers, per mode. It reports ok=false when mode is
+// LeaderOnly, no peer lister is configured, or every follower also failed
+// -- in which case the caller should surface leaderErr as-is.
+//
+// ctx must not already carry the (possibly expired) deadline used for the
+// leader RPC: a DeadlineExceeded leaderErr means that deadline has already
+// passed, so reusing it would fail every follower attempt immediately
+// without ever reaching them. Each follower gets its own fresh timeout
+// derived from ctx, sized by perPeerTimeout.
+func resolveFallback(ctx context.Context, perPeerTimeout time.Duration, cluster LogBackupMetaClient, peers PeerLister, mode LeaderPreferenceMode, region RegionWithLeader, leaderErr error) (checkpoint uint64, ok bool) {
+	if mode == LeaderOnly || peers == nil || !isRetryable(leaderErr) {
+		return 0, false
+	}
+	all, err := peers.Peers(ctx, region.Region.Id)
+	if err != nil {
+		return 0, false
+	}
+	followers := make([]uint64, 0, len(all))
+	for _, p := range all {
+		if p != region.Leader.StoreId {
+			followers = append(followers, p)
+		}
+	}
+
+	query := func(storeID uint64) (uint64, error) {
+		peerCtx := ctx
+		if perPeerTimeout > 0 {
+			var cancel context.CancelFunc
+			peerCtx, cancel = context.WithTimeout(ctx, perPeerTimeout)
+			defer cancel()
+		}
+		return queryPeer(peerCtx, cluster, storeID, region)
+	}
+
+	switch mode {
+	case PreferLeader:
+		for _, f := range followers {
+			if cp, err := query(f); err == nil {
+				return cp, true
+			}
+		}
+		return 0, false
+	case AnyPeer:
+		min, any := uint64(math.MaxUint64), false
+		for _, f := range followers {
+			cp, err := query(f)
+			if err != nil {
+				continue
+			}
+			any = true
+			if cp < min {
+				min = cp
+			}
+		}
+		return min, any
+	default:
+		return 0, false
+	}
+}
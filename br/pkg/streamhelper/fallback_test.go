@@ -0,0 +1,142 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb/br/pkg/streamhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectorLeaderOnlySurfacesPartitionError(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	region, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, region, 1)
+
+	leader := region[0].Leader.StoreId
+	c.partitionLeader(leader)
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	collector := streamhelper.NewCollector(c, cfg)
+	cp := <-collector.SubscribeCheckpoints(context.Background(), region)
+	require.Error(t, cp.Err)
+	require.False(t, cp.IsLowerBound)
+}
+
+func TestCollectorPreferLeaderFallsBackToFollower(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	region, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, region, 1)
+	rid := region[0].Region.Id
+	leader := region[0].Leader.StoreId
+
+	peers, err := c.Peers(context.Background(), rid)
+	require.NoError(t, err)
+	var followers []uint64
+	for _, p := range peers {
+		if p != leader {
+			followers = append(followers, p)
+		}
+	}
+	require.NotEmpty(t, followers, "region should have a non-leader peer")
+
+	// Freeze every follower's checkpoint before advancing the region any
+	// further, so whichever one PreferLeader happens to query first
+	// genuinely trails the leader rather than merely happening to hold
+	// the same value by construction.
+	stale := c.advanceCheckpoints()
+	for _, f := range followers {
+		c.staleFollower(f, rid)
+	}
+	global := c.advanceCheckpoints()
+	require.Less(t, stale, global)
+
+	c.partitionLeader(leader)
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	cfg.LeaderPreference = streamhelper.PreferLeader
+	cfg.Peers = c
+	collector := streamhelper.NewCollector(c, cfg)
+
+	cp := <-collector.SubscribeCheckpoints(context.Background(), region)
+	require.NoError(t, cp.Err)
+	require.True(t, cp.IsLowerBound)
+	// The collector must surface the stale follower's own checkpoint, not
+	// the leader's, proving it's a conservative lower bound rather than a
+	// value that only happens to be <= global.
+	require.Equal(t, stale, cp.Checkpoint)
+	require.Less(t, cp.Checkpoint, global)
+}
+
+func TestCollectorPreferLeaderFallsBackOnRealTimeout(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	region, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, region, 1)
+	global := c.advanceCheckpoints()
+
+	// The leader hangs well past PerRegionRPCTimeout, so its RPC genuinely
+	// fails with context.DeadlineExceeded, rather than an injected
+	// NotLeader/partition error.
+	leader := region[0].Leader.StoreId
+	c.stores[leader].latency = time.Second
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	cfg.PerRegionRPCTimeout = 10 * time.Millisecond
+	cfg.LeaderPreference = streamhelper.PreferLeader
+	cfg.Peers = c
+	collector := streamhelper.NewCollector(c, cfg)
+
+	cp := <-collector.SubscribeCheckpoints(context.Background(), region)
+	require.NoError(t, cp.Err)
+	require.True(t, cp.IsLowerBound)
+	require.LessOrEqual(t, cp.Checkpoint, global)
+}
+
+func TestCollectorAnyPeerTakesMinOfPeers(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	region, err := c.RegionScan(context.Background(), nil, nil, 1)
+	require.NoError(t, err)
+	require.Len(t, region, 1)
+	rid := region[0].Region.Id
+	leader := region[0].Leader.StoreId
+
+	peers, err := c.Peers(context.Background(), rid)
+	require.NoError(t, err)
+	var followers []uint64
+	for _, p := range peers {
+		if p != leader {
+			followers = append(followers, p)
+		}
+	}
+	require.Len(t, followers, 2, "a 3-store cluster should give every region exactly 2 followers")
+
+	// Freeze one follower further behind than the other, so the minimum
+	// really has to come from comparing genuinely divergent checkpoints
+	// rather than two peers that happen to share the leader's value.
+	older := c.advanceCheckpoints()
+	c.staleFollower(followers[0], rid)
+	newer := c.advanceCheckpoints()
+	c.staleFollower(followers[1], rid)
+	c.advanceCheckpoints()
+	require.Less(t, older, newer)
+
+	cfg := streamhelper.DefaultCollectorConfig()
+	cfg.LeaderPreference = streamhelper.AnyPeer
+	cfg.Peers = c
+	collector := streamhelper.NewCollector(c, cfg)
+
+	c.partitionLeader(leader)
+
+	cp := <-collector.SubscribeCheckpoints(context.Background(), region)
+	require.NoError(t, cp.Err)
+	require.True(t, cp.IsLowerBound)
+	// The minimum across followers must be the older, more-stale value,
+	// not merely something <= the current global checkpoint.
+	require.Equal(t, older, cp.Checkpoint)
+}
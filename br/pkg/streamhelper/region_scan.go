@@ -0,0 +1,51 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper
+
+import (
+	"context"
+)
+
+// ReverseRegionScanner extends RegionScanner with a descending scan, so
+// callers can walk the tail of the keyspace without paging through
+// everything in front of it.
+type ReverseRegionScanner interface {
+	RegionScanner
+
+	// RegionScanReverse returns regions in descending start-key order,
+	// between lowerBound (inclusive) and startKey (exclusive), up to limit
+	// entries. A nil startKey means "the end of the keyspace".
+	RegionScanReverse(ctx context.Context, startKey, lowerBound []byte, limit int) ([]RegionWithLeader, error)
+}
+
+// CheckpointOf returns the checkpoint streamhelper knows about for a
+// region, used by FindTailLaggard to recognize a straggler.
+type CheckpointOf func(region RegionWithLeader) (uint64, error)
+
+// FindTailLaggard looks for the laggard nearest the end of the keyspace:
+// the highest start-key region whose checkpoint is behind threshold. It
+// gallops from the tail, doubling the window it asks RegionScanReverse for
+// each round (1, 2, 4, 8, ...), so a laggard k regions from the tail is
+// found in O(log k) round trips instead of paging through the whole
+// keyspace.
+func FindTailLaggard(ctx context.Context, s ReverseRegionScanner, threshold uint64, checkpointOf CheckpointOf) (RegionWithLeader, bool, error) {
+	for window := 1; ; window *= 2 {
+		regions, err := s.RegionScanReverse(ctx, nil, nil, window)
+		if err != nil {
+			return RegionWithLeader{}, false, err
+		}
+		for _, r := range regions {
+			cp, err := checkpointOf(r)
+			if err != nil {
+				return RegionWithLeader{}, false, err
+			}
+			if cp < threshold {
+				return r, true, nil
+			}
+		}
+		if len(regions) < window {
+			// Reached the start of the keyspace without finding a laggard.
+			return RegionWithLeader{}, false, nil
+		}
+	}
+}
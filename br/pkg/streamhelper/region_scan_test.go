@@ -0,0 +1,60 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper_test
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pingcap/tidb/br/pkg/streamhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// countingReverseScanner wraps a fakeCluster to count RegionScanReverse
+// round trips, so the test can assert the bisection is O(log n).
+type countingReverseScanner struct {
+	*fakeCluster
+	calls int64
+}
+
+func (c *countingReverseScanner) RegionScanReverse(ctx context.Context, startKey, lowerBound []byte, limit int) ([]streamhelper.RegionWithLeader, error) {
+	atomic.AddInt64(&c.calls, 1)
+	return c.fakeCluster.RegionScanReverse(ctx, startKey, lowerBound, limit)
+}
+
+func TestFindTailLaggardIsLogarithmic(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	const n = 512
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		keys = append(keys, fmt.Sprintf("%04d", i))
+	}
+	c.splitAndScatter(keys...)
+	global := c.advanceCheckpoints()
+
+	// Force a sort by start key, then plant a laggard a handful of regions
+	// from the tail.
+	_, err := c.RegionScan(context.Background(), nil, nil, len(c.regions)+1)
+	require.NoError(t, err)
+	laggardIdx := len(c.regions) - 5
+	laggardID := c.regions[laggardIdx].id
+	c.updateRegion(laggardID, func(r *region) { r.checkpoint = 0 })
+
+	scanner := &countingReverseScanner{fakeCluster: c}
+	checkpointOf := func(r streamhelper.RegionWithLeader) (uint64, error) {
+		reg := c.findRegionById(r.Region.Id)
+		return reg.checkpoint, nil
+	}
+
+	found, ok, err := streamhelper.FindTailLaggard(context.Background(), scanner, global, checkpointOf)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, laggardID, found.Region.Id)
+
+	maxCalls := bits.Len(uint(len(c.regions))) + 2
+	require.LessOrEqualf(t, int(scanner.calls), maxCalls,
+		"expected O(log n) RegionScanReverse calls, got %d for %d regions", scanner.calls, len(c.regions))
+}
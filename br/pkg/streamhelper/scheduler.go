@@ -0,0 +1,193 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// LagBucket classifies how far a region's checkpoint trails the global
+// checkpoint.
+type LagBucket int
+
+const (
+	// LagFresh is for regions within a second of the global checkpoint.
+	LagFresh LagBucket = iota
+	// LagSeconds is for regions lagging by up to ten seconds.
+	LagSeconds
+	// LagMinute is for regions lagging by up to a minute.
+	LagMinute
+	// LagStraggler is for regions lagging a minute or more.
+	LagStraggler
+
+	lagBucketCount = int(LagStraggler) + 1
+)
+
+// DefaultLagBucketWeights are the weights used to pick among lag buckets,
+// biasing towards regions that are further behind.
+var DefaultLagBucketWeights = [lagBucketCount]int{1, 4, 9, 16}
+
+// LagClassifier buckets a region by how far behind globalCheckpoint its own
+// checkpoint is.
+type LagClassifier func(globalCheckpoint, regionCheckpoint uint64) LagBucket
+
+// ClassifyByDuration is the default LagClassifier: it converts the
+// difference between two TSOs into a duration and buckets by it.
+func ClassifyByDuration(globalCheckpoint, regionCheckpoint uint64) LagBucket {
+	lag := tsoDuration(globalCheckpoint, regionCheckpoint)
+	switch {
+	case lag < time.Second:
+		return LagFresh
+	case lag < 10*time.Second:
+		return LagSeconds
+	case lag < time.Minute:
+		return LagMinute
+	default:
+		return LagStraggler
+	}
+}
+
+// tsoDuration estimates the wall-clock gap between two TSOs using the
+// physical-time component packed into their high bits, matching how PD
+// encodes a TSO.
+func tsoDuration(a, b uint64) time.Duration {
+	const physicalShiftBits = 18
+	pa, pb := a>>physicalShiftBits, b>>physicalShiftBits
+	if pa < pb {
+		pa, pb = pb, pa
+	}
+	return time.Duration(pa-pb) * time.Millisecond
+}
+
+// SchedulerMetrics exposes the observable state of a CheckpointScheduler for
+// monitoring, one counter per bucket.
+type SchedulerMetrics struct {
+	BucketSizes [lagBucketCount]int
+	PickCounts  [lagBucketCount]int
+}
+
+// CheckpointScheduler draws regions to re-query for their checkpoint,
+// favoring regions that lag further behind the global checkpoint. Regions
+// are kept in one FIFO per LagBucket; Pop rolls a weighted random index
+// over the non-empty buckets and pops from it.
+type CheckpointScheduler struct {
+	classifier LagClassifier
+	weights    [lagBucketCount]int
+
+	mu      sync.Mutex
+	buckets [lagBucketCount][]RegionWithLeader
+	bucket  map[uint64]LagBucket
+	metrics SchedulerMetrics
+}
+
+// NewCheckpointScheduler creates a CheckpointScheduler using classifier to
+// bucket regions and weights to bias the weighted draw. A nil classifier
+// defaults to ClassifyByDuration, and nil weights default to
+// DefaultLagBucketWeights.
+func NewCheckpointScheduler(classifier LagClassifier, weights *[lagBucketCount]int) *CheckpointScheduler {
+	if classifier == nil {
+		classifier = ClassifyByDuration
+	}
+	w := DefaultLagBucketWeights
+	if weights != nil {
+		w = *weights
+	}
+	return &CheckpointScheduler{
+		classifier: classifier,
+		weights:    w,
+		bucket:     map[uint64]LagBucket{},
+	}
+}
+
+// Push adds or re-classifies a region given the current global checkpoint
+// and the region's own checkpoint. A region already tracked is moved to
+// its (possibly new) bucket, so a formerly-lagging region that catches up
+// drops to a lower-priority bucket.
+func (s *CheckpointScheduler) Push(globalCheckpoint uint64, region RegionWithLeader, regionCheckpoint uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := region.Region.Id
+	if old, ok := s.bucket[id]; ok {
+		s.removeLocked(old, id)
+	}
+	b := s.classifier(globalCheckpoint, regionCheckpoint)
+	s.buckets[b] = append(s.buckets[b], region)
+	s.bucket[id] = b
+	s.metrics.BucketSizes[b]++
+}
+
+func (s *CheckpointScheduler) removeLocked(b LagBucket, id uint64) {
+	rs := s.buckets[b]
+	for i, r := range rs {
+		if r.Region.Id == id {
+			s.buckets[b] = append(rs[:i], rs[i+1:]...)
+			s.metrics.BucketSizes[b]--
+			break
+		}
+	}
+}
+
+// Pop draws the next region to query, choosing among the non-empty buckets
+// proportionally to their configured weights, so an empty bucket is never
+// chosen. It returns false if every bucket is empty.
+func (s *CheckpointScheduler) Pop() (RegionWithLeader, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.pickBucketLocked()
+	if !ok {
+		return RegionWithLeader{}, false
+	}
+	rs := s.buckets[b]
+	r := rs[0]
+	s.buckets[b] = rs[1:]
+	delete(s.bucket, r.Region.Id)
+	s.metrics.BucketSizes[b]--
+	s.metrics.PickCounts[b]++
+	return r, true
+}
+
+// pickBucketLocked rolls a weighted random index over the non-empty
+// buckets via a linear scan of their cumulative weights, and must be called with
+// s.mu held.
+func (s *CheckpointScheduler) pickBucketLocked() (LagBucket, bool) {
+	total := 0
+	for b := 0; b < lagBucketCount; b++ {
+		if len(s.buckets[b]) > 0 {
+			total += s.weights[b]
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	roll := rand.Intn(total)
+	cum := 0
+	for b := 0; b < lagBucketCount; b++ {
+		if len(s.buckets[b]) == 0 {
+			continue
+		}
+		cum += s.weights[b]
+		if roll < cum {
+			return LagBucket(b), true
+		}
+	}
+	// Not reached while the weights are non-negative, since roll < total
+	// and s.mu is held; fall back to the most lagging non-empty bucket.
+	for b := lagBucketCount - 1; b >= 0; b-- {
+		if len(s.buckets[b]) > 0 {
+			return LagBucket(b), true
+		}
+	}
+	return 0, false
+}
+
+// Metrics returns a snapshot of the current bucket sizes and cumulative
+// pick counts.
+func (s *CheckpointScheduler) Metrics() SchedulerMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metrics
+}
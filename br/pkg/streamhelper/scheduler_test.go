@@ -0,0 +1,174 @@
+// Copyright 2024 PingCAP, Inc. Licensed under Apache-2.0.
+
+package streamhelper_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/tidb/br/pkg/streamhelper"
+	"github.com/stretchr/testify/require"
+)
+
+// numericLagClassifier buckets by a plain numeric difference, matching the
+// synthetic, monotonically-increasing checkpoints produced by the fake
+// cluster (which aren't real TSOs).
+func numericLagClassifier(global, regionCheckpoint uint64) streamhelper.LagBucket {
+	if regionCheckpoint >= global {
+		return streamhelper.LagFresh
+	}
+	lag := global - regionCheckpoint
+	switch {
+	case lag < 10:
+		return streamhelper.LagFresh
+	case lag < 100:
+		return streamhelper.LagSeconds
+	case lag < 1000:
+		return streamhelper.LagMinute
+	default:
+		return streamhelper.LagStraggler
+	}
+}
+
+func TestCheckpointSchedulerFavorsStragglers(t *testing.T) {
+	c := createFakeCluster(t, 3, false)
+	keys := make([]string, 0, 32)
+	for i := 0; i < 32; i++ {
+		keys = append(keys, fmt.Sprintf("%02d", i))
+	}
+	c.splitAndScatter(keys...)
+
+	const global = uint64(100000)
+	stragglerID := c.regions[0].id
+	c.updateRegion(stragglerID, func(r *region) { r.checkpoint = 0 })
+	for _, r := range c.regions[1:] {
+		rid := r.id
+		c.updateRegion(rid, func(r *region) { r.checkpoint = global - 1 })
+	}
+
+	regions, err := c.RegionScan(context.Background(), nil, nil, len(c.regions)+1)
+	require.NoError(t, err)
+
+	sched := streamhelper.NewCheckpointScheduler(numericLagClassifier, nil)
+	checkpointOf := map[uint64]uint64{stragglerID: 0}
+	for _, r := range regions {
+		cp, ok := checkpointOf[r.Region.Id]
+		if !ok {
+			cp = global - 1
+		}
+		sched.Push(global, r, cp)
+	}
+
+	picks := map[uint64]int{}
+	const rounds = 2000
+	for i := 0; i < rounds; i++ {
+		r, ok := sched.Pop()
+		require.True(t, ok)
+		picks[r.Region.Id]++
+		// Re-classify immediately so the straggler keeps competing.
+		sched.Push(global, r, checkpointOf[r.Region.Id])
+	}
+
+	fresh := 0
+	for id, n := range picks {
+		if id != stragglerID {
+			fresh += n
+		}
+	}
+	avgFresh := float64(fresh) / float64(len(regions)-1)
+	require.Greaterf(t, float64(picks[stragglerID]), avgFresh,
+		"straggler should be picked more often than the average fresh region: %v", picks)
+
+	metrics := sched.Metrics()
+	require.Greater(t, metrics.PickCounts[streamhelper.LagStraggler], 0)
+}
+
+// TestCheckpointSchedulerAdvancesFasterThanRoundRobin replays an
+// advanceCheckpoints-style tick -- every region's true checkpoint grows by
+// the same amount each round, as if wall-clock time were simply passing --
+// against a fixed one-query-per-round budget, starting from a snapshot
+// where a couple of regions are already well behind the rest (e.g. from a
+// transient stall caught by an earlier full scan). It asserts that letting
+// CheckpointScheduler decide which region to re-query keeps the reported
+// global checkpoint -- the minimum of every region's last-known value --
+// closer to the true, still-growing value of the regions that matter than
+// a fixed round-robin visitation order does, which wastes its budget
+// re-confirming regions that were already fresh.
+func TestCheckpointSchedulerAdvancesFasterThanRoundRobin(t *testing.T) {
+	const (
+		numRegions    = 20
+		numStragglers = 2
+		rounds        = 400
+	)
+
+	initial := make([]uint64, numRegions)
+	for i := range initial {
+		initial[i] = 100000
+	}
+	for i := 0; i < numStragglers; i++ {
+		initial[i] = 0
+	}
+
+	regions := make([]streamhelper.RegionWithLeader, numRegions)
+	for i := range regions {
+		regions[i] = streamhelper.RegionWithLeader{Region: &metapb.Region{Id: uint64(i + 1)}}
+	}
+
+	minOf := func(vs []uint64) uint64 {
+		m := vs[0]
+		for _, v := range vs[1:] {
+			if v < m {
+				m = v
+			}
+		}
+		return m
+	}
+
+	// roundRobinGlobal visits every region in a fixed cyclic order, one
+	// query per round, regardless of how far behind it is.
+	roundRobinGlobal := func() uint64 {
+		flushed := append([]uint64(nil), initial...)
+		known := make([]uint64, numRegions)
+		next := 0
+		for round := 0; round < rounds; round++ {
+			for i := range flushed {
+				flushed[i]++
+			}
+			known[next] = flushed[next]
+			next = (next + 1) % numRegions
+		}
+		return minOf(known)
+	}
+
+	// weightedGlobal spends the same one-query-per-round budget, but asks
+	// CheckpointScheduler which region to query, re-pushing every region's
+	// current lag -- against the round count itself, which stands in for
+	// "where a region ought to be if it were being watched continuously"
+	// -- the way a periodic rescan would.
+	weightedGlobal := func() uint64 {
+		flushed := append([]uint64(nil), initial...)
+		known := make([]uint64, numRegions)
+		sched := streamhelper.NewCheckpointScheduler(numericLagClassifier, nil)
+		for round := 0; round < rounds; round++ {
+			for i := range flushed {
+				flushed[i]++
+			}
+			ref := uint64(round + 1)
+			for i, r := range regions {
+				sched.Push(ref, r, known[i])
+			}
+			r, ok := sched.Pop()
+			require.True(t, ok)
+			idx := r.Region.Id - 1
+			known[idx] = flushed[idx]
+		}
+		return minOf(known)
+	}
+
+	rr := roundRobinGlobal()
+	weighted := weightedGlobal()
+	require.Greaterf(t, weighted, rr,
+		"weighted scheduling should advance the global checkpoint faster than round-robin: weighted=%d round-robin=%d", weighted, rr)
+}